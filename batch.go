@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// BatchProgress описывает результат обработки одного файла и передается в
+// BatchOptions.Progress по мере продвижения BatchProcess
+type BatchProgress struct {
+	Path  string // Путь к исходному файлу относительно inputDir
+	Index int    // Порядковый номер файла в очереди обработки (с 1)
+	Total int    // Общее количество файлов, прошедших фильтры Include/Exclude
+	Err   error  // Ошибка обработки файла, если есть
+}
+
+// BatchOptions настраивает поведение BatchProcess
+type BatchOptions struct {
+	Include []string // Glob-шаблоны имен файлов, которые нужно обработать (пусто — все файлы)
+	Exclude []string // Glob-шаблоны имен файлов, которые нужно пропустить
+
+	// NameTemplate — шаблон text/template для имени результирующего файла,
+	// например "chapter-{{.Index}}{{.Ext}}". Доступные поля: Name (имя без
+	// расширения), Ext (расширение с точкой), Index (порядковый номер с 1).
+	// Если пусто, имя файла сохраняется как в inputDir
+	NameTemplate string
+
+	// Progress, если задан, вызывается после обработки каждого файла
+	// (из воркер-горутины, конкурентно)
+	Progress func(BatchProgress)
+}
+
+// batchNameData — данные, доступные шаблону BatchOptions.NameTemplate
+type batchNameData struct {
+	Name  string
+	Ext   string
+	Index int
+}
+
+// BatchProcess обходит inputDir, накладывает водяной знак wm на каждое
+// найденное изображение и сохраняет результат в outputDir, сохраняя
+// относительную структуру каталогов. Файлы обрабатываются конкурентно
+// пулом воркеров размером runtime.NumCPU()
+func BatchProcess(inputDir, outputDir string, wm Watermarker, opts BatchOptions) error {
+	type job struct {
+		path  string
+		rel   string
+		index int
+	}
+
+	var jobs []job
+	if err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return err
+		}
+		if !matchesBatchFilters(filepath.Base(rel), opts.Include, opts.Exclude) {
+			return nil
+		}
+		jobs = append(jobs, job{path: path, rel: rel, index: len(jobs)})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("walk %q: %w", inputDir, err)
+	}
+
+	total := len(jobs)
+	workers := runtime.NumCPU()
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > total {
+		workers = total
+	}
+
+	jobCh := make(chan job)
+	errCh := make(chan error, total)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				err := processBatchFile(inputDir, outputDir, wm, opts, j.path, j.rel, j.index, total)
+				if opts.Progress != nil {
+					opts.Progress(BatchProgress{Path: j.rel, Index: j.index + 1, Total: total, Err: err})
+				}
+				if err != nil {
+					errCh <- err
+				}
+			}
+		}()
+	}
+
+	for _, j := range jobs {
+		jobCh <- j
+	}
+	close(jobCh)
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		return err // Возвращаем первую встреченную ошибку
+	}
+	return nil
+}
+
+// processBatchFile декодирует один файл, накладывает водяной знак и
+// записывает результат по соответствующему пути в outputDir
+func processBatchFile(inputDir, outputDir string, wm Watermarker, opts BatchOptions, path, rel string, index, total int) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", path, err)
+	}
+	defer src.Close()
+
+	baseImage, format, err := image.Decode(src)
+	if err != nil {
+		return fmt.Errorf("decode %q: %w", path, err)
+	}
+
+	result, err := wm.Apply(baseImage)
+	if err != nil {
+		return fmt.Errorf("watermark %q: %w", path, err)
+	}
+
+	outRel := rel
+	if opts.NameTemplate != "" {
+		if outRel, err = renderBatchName(opts.NameTemplate, rel, index); err != nil {
+			return err
+		}
+	}
+
+	outPath := filepath.Join(outputDir, outRel)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("create output dir for %q: %w", outPath, err)
+	}
+
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", outPath, err)
+	}
+	defer outFile.Close()
+
+	if err := encodeImageFormat(outFile, result, format); err != nil {
+		return fmt.Errorf("encode %q: %w", outPath, err)
+	}
+	return nil
+}
+
+// renderBatchName применяет NameTemplate к имени файла rel (относительный
+// путь внутри inputDir), сохраняя исходную поддиректорию
+func renderBatchName(nameTemplate, rel string, index int) (string, error) {
+	dir := filepath.Dir(rel)
+	base := filepath.Base(rel)
+	ext := filepath.Ext(base)
+
+	tmpl, err := template.New("batchName").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parse name template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := batchNameData{Name: strings.TrimSuffix(base, ext), Ext: ext, Index: index + 1}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render name template: %w", err)
+	}
+
+	if dir == "." {
+		return buf.String(), nil
+	}
+	return filepath.Join(dir, buf.String()), nil
+}
+
+// matchesBatchFilters проверяет имя файла против списков Include/Exclude
+func matchesBatchFilters(name string, include, exclude []string) bool {
+	if len(include) > 0 {
+		matched := false
+		for _, pattern := range include {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
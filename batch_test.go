@@ -0,0 +1,121 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchesBatchFilters(t *testing.T) {
+	cases := []struct {
+		name             string
+		fileName         string
+		include, exclude []string
+		want             bool
+	}{
+		{"no filters matches everything", "photo.png", nil, nil, true},
+		{"include matches", "photo.png", []string{"*.png"}, nil, true},
+		{"include does not match", "photo.gif", []string{"*.png"}, nil, false},
+		{"exclude wins over include", "thumb.png", []string{"*.png"}, []string{"thumb*"}, false},
+		{"exclude only", "photo.jpg", nil, []string{"*.tmp"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := matchesBatchFilters(c.fileName, c.include, c.exclude); got != c.want {
+				t.Errorf("matchesBatchFilters(%q, %v, %v) = %v, want %v", c.fileName, c.include, c.exclude, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRenderBatchName(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		rel      string
+		index    int
+		want     string
+		wantErr  bool
+	}{
+		{"index and ext", "chapter-{{.Index}}{{.Ext}}", "01/page.png", 0, "01/chapter-1.png", false},
+		{"name only, no subdir", "{{.Name}}-small{{.Ext}}", "photo.jpg", 4, "photo-small.jpg", false},
+		{"invalid template", "{{.Nope", "photo.jpg", 0, "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := renderBatchName(c.template, c.rel, c.index)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("renderBatchName(%q) expected error, got none", c.template)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderBatchName(%q) unexpected error: %v", c.template, err)
+			}
+			if got != c.want {
+				t.Errorf("renderBatchName(%q, %q, %d) = %q, want %q", c.template, c.rel, c.index, got, c.want)
+			}
+		})
+	}
+}
+
+// identityWatermark реализует Watermarker, ничего не меняя в изображении —
+// этого достаточно, чтобы проверить обход каталогов, фильтрацию и
+// именование BatchProcess, не завися от файлов шрифтов
+type identityWatermark struct{}
+
+func (identityWatermark) Apply(baseImage image.Image) (image.Image, error) {
+	return baseImage, nil
+}
+
+func writeTestPNG(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir for %q: %v", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %q: %v", path, err)
+	}
+	defer f.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encode %q: %v", path, err)
+	}
+}
+
+func TestBatchProcess(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeTestPNG(t, filepath.Join(inputDir, "a.png"))
+	writeTestPNG(t, filepath.Join(inputDir, "b.png"))
+	writeTestPNG(t, filepath.Join(inputDir, "skip.tmp.png"))
+	writeTestPNG(t, filepath.Join(inputDir, "nested", "c.png"))
+
+	opts := BatchOptions{
+		Exclude:      []string{"skip.*"},
+		NameTemplate: "{{.Name}}-wm{{.Ext}}",
+	}
+
+	if err := BatchProcess(inputDir, outputDir, identityWatermark{}, opts); err != nil {
+		t.Fatalf("BatchProcess: %v", err)
+	}
+
+	for _, rel := range []string{"a-wm.png", "b-wm.png", filepath.Join("nested", "c-wm.png")} {
+		if _, err := os.Stat(filepath.Join(outputDir, rel)); err != nil {
+			t.Errorf("expected output file %q: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "skip.tmp-wm.png")); !os.IsNotExist(err) {
+		t.Errorf("excluded file should not have been processed, stat err = %v", err)
+	}
+}
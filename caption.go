@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// CaptionBand задает, к какому краю изображения добавляется полоса подписи
+type CaptionBand int
+
+const (
+	CaptionBottom CaptionBand = iota // Полоса подписи снизу изображения
+	CaptionTop                       // Полоса подписи сверху изображения
+)
+
+// Caption рендерит подпись на отдельной полосе (сплошной или
+// полупрозрачной), расширяющей высоту изображения, в отличие от
+// полупрозрачного TextWatermark, накладываемого поверх самого изображения.
+// Подходит для атрибуции источника/авторства
+type Caption struct {
+	Text       string      // Текст подписи
+	Font       string      // Путь к файлу шрифта
+	Size       float64     // Размер шрифта в пунктах
+	DPI        float64     // Разрешение для расчета размера шрифта (по умолчанию 72)
+	Color      color.Color // Цвет текста
+	Background color.Color // Цвет полосы подписи (если не задан, используется непрозрачный черный)
+	Margin     float64     // Отступ текста от краев полосы
+	Width      float64     // Ширина области переноса текста (по умолчанию — ширина изображения)
+	Band       CaptionBand // Где разместить полосу: сверху или снизу
+}
+
+// loadCaptionFontFace загружает шрифт с учетом DPI; gg.Context.LoadFontFace
+// не принимает DPI, поэтому шрифт грузится напрямую через freetype/truetype
+func loadCaptionFontFace(path string, size, dpi float64) (font.Face, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read font %q: %w", path, err)
+	}
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %q: %w", path, err)
+	}
+	return truetype.NewFace(f, &truetype.Options{Size: size, DPI: dpi}), nil
+}
+
+// shapeBidiText переупорядочивает текст со смешанным направлением (например,
+// персидский/арабский вперемешку с латиницей) в визуальный порядок перед
+// передачей в gg, который сам по себе не выполняет bidi-преобразование
+func shapeBidiText(s string) string {
+	var p bidi.Paragraph
+	if _, err := p.SetString(s); err != nil {
+		return s
+	}
+	ordering, err := p.Order()
+	if err != nil {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < ordering.NumRuns(); i++ {
+		run := ordering.Run(i)
+		if run.Direction() == bidi.RightToLeft {
+			sb.WriteString(bidi.ReverseString(run.String()))
+		} else {
+			sb.WriteString(run.String())
+		}
+	}
+	return sb.String()
+}
+
+// Apply добавляет полосу с подписью к изображению, реализуя интерфейс
+// Watermarker
+func (c *Caption) Apply(baseImage image.Image) (image.Image, error) {
+	bounds := baseImage.Bounds()
+
+	dpi := c.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	background := c.Background
+	if background == nil {
+		background = color.Black
+	}
+
+	face, err := loadCaptionFontFace(c.Font, c.Size, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	width := c.Width
+	if width <= 0 {
+		width = float64(bounds.Dx())
+	}
+	textWidth := width - 2*c.Margin
+	if textWidth <= 0 {
+		textWidth = width
+	}
+
+	const lineSpacing = 1.5
+	measure := gg.NewContext(1, 1)
+	measure.SetFontFace(face)
+	// Переносим текст в логическом порядке (как его вводит пользователь),
+	// а не в визуальном, иначе WordWrap меряет и разбивает уже
+	// переставленные bidi-рантаймом глифы и ломает границы слов в
+	// многострочных RTL-подписях. bidi-преобразование применяется к каждой
+	// уже перенесенной строке по отдельности
+	lines := measure.WordWrap(c.Text, textWidth)
+	for i, line := range lines {
+		lines[i] = shapeBidiText(line)
+	}
+	text := strings.Join(lines, "\n")
+	fontHeight := measure.FontHeight()
+	textHeight := float64(len(lines))*fontHeight*lineSpacing - (lineSpacing-1)*fontHeight
+	bandHeight := int(math.Ceil(textHeight + 2*c.Margin))
+
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+bandHeight))
+
+	var imageRect, bandRect image.Rectangle
+	if c.Band == CaptionTop {
+		bandRect = image.Rect(0, 0, bounds.Dx(), bandHeight)
+		imageRect = image.Rect(0, bandHeight, bounds.Dx(), canvas.Bounds().Dy())
+	} else {
+		imageRect = image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+		bandRect = image.Rect(0, bounds.Dy(), bounds.Dx(), canvas.Bounds().Dy())
+	}
+
+	draw.Draw(canvas, bandRect, &image.Uniform{background}, image.Point{}, draw.Src)
+	draw.Draw(canvas, imageRect, baseImage, bounds.Min, draw.Over)
+
+	dc := gg.NewContextForRGBA(canvas)
+	dc.SetFontFace(face)
+	dc.SetColor(c.Color)
+	dc.DrawStringWrapped(text, float64(bandRect.Min.X)+width/2, float64(bandRect.Min.Y)+c.Margin, 0.5, 0, textWidth, lineSpacing, gg.AlignCenter)
+
+	return dc.Image(), nil
+}
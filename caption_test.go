@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestShapeBidiText(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain latin is untouched", "hello world", "hello world"},
+		{"pure rtl run is reversed", "שלום", "םולש"},
+		{"empty string", "", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := shapeBidiText(c.in); got != c.want {
+				t.Errorf("shapeBidiText(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
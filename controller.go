@@ -2,189 +2,381 @@ package main
 
 import (
 	"bytes"
-	"encoding/base64"
+	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"io"
+	"net"
 	"net/http"
-	"os"
-	"text/template"
-
-	"github.com/fogleman/gg"
-	"github.com/nfnt/resize"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// BaseWatermark содержит основные параметры для водяного знака
-type BaseWatermark struct {
-	Opacity float64     // Прозрачность водяного знака
-	Color   color.Color // Цвет водяного знака
-	Font    string      // Шрифт для текстового водяного знака
-	Size    float64     // Размер текста водяного знака
-	Rotate  float64     // Угол вращения текста водяного знака
-}
-
-// Watermark представляет собой графический водяной знак
-type Watermark struct {
-	BaseWatermark         // Встраивание базовых параметров
-	Path          string  // Путь к файлу изображения водяного знака
-	Scale         float64 // Масштабирование водяного знака
-}
-
-// TextWatermark представляет собой текстовый водяной знак
-type TextWatermark struct {
-	BaseWatermark        // Встраивание базовых параметров
-	Text          string // Текст для водяного знака
-}
-
-// CreateImage создает изображение с текстовым водяным знаком
-func (t *TextWatermark) CreateImage(width, height float64) image.Image {
-	dc := gg.NewContext(int(width), int(height)) // Создаем новый контекст рисования
-	dc.SetRGBA(1, 1, 1, 0)                       // Устанавливаем прозрачный фон
-	dc.Clear()                                   // Очищаем контекст
-	dc.SetColor(t.Color)                         // Устанавливаем цвет текста
-	if err := dc.LoadFontFace(t.Font, t.Size); err != nil {
-		panic(err) // Обрабатываем ошибку при загрузке шрифта
-	}
-	angle := -t.Rotate * (3.14 / 180)                          // Преобразуем угол в радианы
-	dc.Push()                                                  // Запоминаем текущее состояние контекста
-	dc.RotateAbout(angle, width/2, height/2)                   // Поворачиваем контекст
-	dc.DrawStringAnchored(t.Text, width/2, height/2, 0.5, 0.5) // Рисуем текст в центре
-	dc.Pop()                                                   // Восстанавливаем состояние контекста
-	return dc.Image()                                          // Возвращаем изображение
-}
-
-// ApplyToImage накладывает графический водяной знак на базовое изображение
-func (w *Watermark) ApplyToImage(baseImage image.Image) image.Image {
-	bounds := baseImage.Bounds()                                   // Получаем границы базового изображения
-	result := image.NewRGBA(bounds)                                // Создаем новое изображение для результата
-	draw.Draw(result, bounds, baseImage, image.Point{}, draw.Over) // Рисуем базовое изображение на результирующем
-
-	if w.Path != "" { // Проверяем, задан ли путь к изображению водяного знака
-		watermarkFile, err := os.Open(w.Path) // Открываем файл водяного знака
-		if err != nil {
-			panic(err) // Обрабатываем ошибку при открытии файла
-		}
-		defer watermarkFile.Close()                      // Закрываем файл после завершения работы
-		watermarkImage, err := png.Decode(watermarkFile) // Декодируем изображение водяного знака
-		if err != nil {
-			panic(err) // Обрабатываем ошибку
+// maxFetchBodyBytes ограничивает размер тела ответа, читаемого
+// handleWatermarkFromURL, тем же лимитом, что и multipart-загрузка в
+// handleWatermarkUpload, чтобы большой или медленно отдаваемый src не исчерпал
+// память
+const maxFetchBodyBytes = 32 << 20
+
+// fetchHTTPClient используется handleWatermarkFromURL для загрузки исходного
+// изображения по src; таймаут не дает запросу зависнуть на медленном или
+// намеренно тянущем время сервере. CheckRedirect прогоняет каждый редирект
+// через ту же проверку SSRF, что и исходный URL — иначе разрешенный хост мог
+// бы редиректнуть на loopback/внутренний адрес в обход validateFetchURL
+var fetchHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if _, err := validateFetchURL(req.URL.String()); err != nil {
+			return fmt.Errorf("redirect to disallowed URL: %w", err)
 		}
+		return nil
+	},
+}
+
+// contentTypeForFormat возвращает MIME-тип ответа для формата, определенного
+// image.Decode ("jpeg", "png", "gif")
+func contentTypeForFormat(format string) string {
+	switch format {
+	case "jpeg":
+		return "image/jpeg"
+	case "gif":
+		return "image/gif"
+	default:
+		return "image/png"
+	}
+}
+
+// encodeImage кодирует изображение в указанном формате и пишет его прямо в w
+// вместе с соответствующим заголовком Content-Type
+func encodeImage(w http.ResponseWriter, img image.Image, format string) error {
+	w.Header().Set("Content-Type", contentTypeForFormat(format))
+	return encodeImageFormat(w, img, format)
+}
+
+// encodeImageFormat кодирует изображение в указанном формате в произвольный
+// io.Writer, без привязки к HTTP-ответу (используется также при пакетной
+// обработке, см. batch.go)
+func encodeImageFormat(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, nil)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// parseHexColor разбирает цвет в формате "#RRGGBB" или "RRGGBB"
+func parseHexColor(hex string) (color.Color, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid hex color %q", hex)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color %q: %w", hex, err)
+	}
+	return color.RGBA{
+		R: uint8(v >> 16),
+		G: uint8(v >> 8),
+		B: uint8(v),
+		A: 255,
+	}, nil
+}
+
+// parsePosition разбирает позицию водяного знака из строкового параметра
+// запроса (top-left, top-right, bottom-left, bottom-right, center, tile)
+func parsePosition(s string) (Position, error) {
+	switch strings.ToLower(s) {
+	case "", "center":
+		return Center, nil
+	case "top-left":
+		return TopLeft, nil
+	case "top-right":
+		return TopRight, nil
+	case "bottom-left":
+		return BottomLeft, nil
+	case "bottom-right":
+		return BottomRight, nil
+	case "tile":
+		return Tile, nil
+	default:
+		return Center, fmt.Errorf("unknown position %q", s)
+	}
+}
+
+// parseFloatParam разбирает числовой параметр запроса, возвращая def, если
+// параметр не задан
+func parseFloatParam(r *http.Request, name string, def float64) (float64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, raw, err)
+	}
+	return v, nil
+}
 
-		if w.Scale != 1 { // Проверяем, требуется ли изменение масштаба
-			bounds := watermarkImage.Bounds()                                                    // Получаем границы изображения водяного знака
-			newWidth := uint(float64(bounds.Dx()) * w.Scale)                                     // Рассчитываем новый размер по ширине
-			newHeight := uint(float64(bounds.Dy()) * w.Scale)                                    // Рассчитываем новый размер по высоте
-			watermarkImage = resize.Resize(newWidth, newHeight, watermarkImage, resize.Bilinear) // Масштабируем изображение
+// baseWatermarkFromQuery собирает общие параметры водяного знака (цвет,
+// шрифт, размер, прозрачность, поворот, позиция, смещения) из query-строки
+// запроса
+func baseWatermarkFromQuery(r *http.Request) (BaseWatermark, error) {
+	q := r.URL.Query()
+
+	base := BaseWatermark{
+		Color: color.RGBA{255, 255, 255, 255},
+		Font:  q.Get("font"),
+	}
+	if base.Font == "" {
+		base.Font = "Nunito-Medium.ttf"
+	}
+
+	var err error
+	if hex := q.Get("color"); hex != "" {
+		if base.Color, err = parseHexColor(hex); err != nil {
+			return BaseWatermark{}, err
 		}
+	}
+	if base.Size, err = parseFloatParam(r, "size", 35); err != nil {
+		return BaseWatermark{}, err
+	}
+	if base.Opacity, err = parseFloatParam(r, "opacity", 0.6); err != nil {
+		return BaseWatermark{}, err
+	}
+	if base.Rotate, err = parseFloatParam(r, "rotation", 0); err != nil {
+		return BaseWatermark{}, err
+	}
+	if base.Dx, err = parseFloatParam(r, "dx", 0); err != nil {
+		return BaseWatermark{}, err
+	}
+	if base.Dy, err = parseFloatParam(r, "dy", 0); err != nil {
+		return BaseWatermark{}, err
+	}
+	if base.Position, err = parsePosition(q.Get("position")); err != nil {
+		return BaseWatermark{}, err
+	}
+
+	return base, nil
+}
+
+// buildWatermarkPipeline собирает конвейер из доступных этапов (текстовый
+// и/или графический водяной знак, если передан watermarkImage) по параметрам
+// из query-строки запроса, не применяя его ни к какому изображению — это
+// позволяет переиспользовать один и тот же конвейер как для статичного
+// изображения, так и для покадровой обработки анимации (см. ApplyToGIF)
+func buildWatermarkPipeline(r *http.Request, watermarkImage image.Image) (*Pipeline, error) {
+	base, err := baseWatermarkFromQuery(r)
+	if err != nil {
+		return nil, err
+	}
 
-		// Рассчитываем позицию накладываемого водяного знака (центр)
-		offset := image.Point{
-			X: (bounds.Dx() - watermarkImage.Bounds().Dx()) / 2,
-			Y: (bounds.Dy() - watermarkImage.Bounds().Dy()) / 2,
+	var pipeline Pipeline
+	if watermarkImage != nil {
+		scale, err := parseFloatParam(r, "scale", 1)
+		if err != nil {
+			return nil, err
 		}
+		wm := &Watermark{BaseWatermark: base, Scale: scale}
+		// Изображение водяного знака уже декодировано запросом, поэтому
+		// накладываем его напрямую, минуя загрузку по Path
+		pipeline.Stages = append(pipeline.Stages, watermarkImageStage{wm, watermarkImage})
+	}
 
-		// Накладываем водяной знак
-		draw.Draw(result, watermarkImage.Bounds().Add(offset), watermarkImage, image.Point{}, draw.Over)
+	if text := r.URL.Query().Get("text"); text != "" {
+		tw := &TextWatermark{BaseWatermark: base, Text: text}
+		pipeline.Stages = append(pipeline.Stages, tw)
 	}
 
-	return result // Возвращаем изображение с наложенным водяным знаком
+	return &pipeline, nil
 }
 
-// CreateWatermarkedImage создает изображение с текстовым водяным знаком
-func (w *TextWatermark) CreateWatermarkedImage(baseImage image.Image) image.Image {
-	textImage := w.CreateImage(float64(baseImage.Bounds().Dx()), float64(baseImage.Bounds().Dy())) // Генерируем текстовый водяной знак
-	result := image.NewRGBA(baseImage.Bounds())                                                    // Создаем блок для результата
-	draw.Draw(result, baseImage.Bounds(), baseImage, image.Point{}, draw.Over)                     // Копируем базовое изображение в результат
+// watermarkImageStage — этап конвейера, накладывающий уже декодированное
+// изображение водяного знака (полученное не из Watermark.Path, а,
+// например, из multipart-загрузки)
+type watermarkImageStage struct {
+	wm             *Watermark
+	watermarkImage image.Image
+}
 
-	// Накладываем текстовый водяной знак
-	draw.DrawMask(result, result.Bounds(), textImage, image.Point{}, &image.Uniform{color.Alpha{uint8(255 * w.Opacity)}}, image.Point{}, draw.Over)
-	return result // Возвращаем итоговое изображение
+func (s watermarkImageStage) Apply(baseImage image.Image) (image.Image, error) {
+	return s.wm.applyWatermarkImage(baseImage, s.watermarkImage), nil
 }
 
-// encodeImageToBase64 кодирует изображение в формат Base64
-func encodeImageToBase64(img image.Image, imgType string) string {
-	buff := new(bytes.Buffer) // Создаем новый буфер для кодирования
-	switch imgType {
-	case "jpeg":
-		jpeg.Encode(buff, img, nil) // Кодируем в формате JPEG
-	case "png":
-		png.Encode(buff, img) // Кодируем в формате PNG
+// outputFormat возвращает формат вывода: явно запрошенный параметром
+// format, либо формат исходного изображения
+func outputFormat(r *http.Request, srcFormat string) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	return srcFormat
+}
+
+// respondWatermarked определяет формат исходного изображения data, собирает
+// конвейер водяного знака из параметров запроса r и пишет результат в w с
+// корректным Content-Type. Анимированные GIF обрабатываются покадрово через
+// ApplyToGIF и всегда возвращаются как GIF, чтобы сохранить анимацию;
+// остальные форматы применяют конвейер к единственному кадру и могут быть
+// перекодированы через параметр format
+func respondWatermarked(w http.ResponseWriter, r *http.Request, data []byte, watermarkImage image.Image) {
+	_, srcFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot decode image: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pipeline, err := buildWatermarkPipeline(r, watermarkImage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if srcFormat == "gif" {
+		src, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("cannot decode gif: %v", err), http.StatusBadRequest)
+			return
+		}
+		result, err := ApplyToGIF(src, pipeline)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		if err := gif.EncodeAll(w, result); err != nil {
+			http.Error(w, fmt.Sprintf("cannot encode result: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	baseImage, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot decode image: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := pipeline.Apply(baseImage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := encodeImage(w, result, outputFormat(r, srcFormat)); err != nil {
+		http.Error(w, fmt.Sprintf("cannot encode result: %v", err), http.StatusInternalServerError)
 	}
-	return base64.StdEncoding.EncodeToString(buff.Bytes()) // Возвращаем строку Base64
 }
 
-// handleWatermarkedImages обрабатывает запрос для создания изображений с водяными знаками
-func handleWatermarkedImages(w http.ResponseWriter, r *http.Request) {
-	// Создаем экземпляр графического водяного знака
-	imageWatermark := &Watermark{
-		BaseWatermark: BaseWatermark{
-			Opacity: 0.6, // Устанавливаем прозрачность
-		},
-		Path:  "FG-copyright-mini.png", // Путь к изображению водяного знака
-		Scale: 1.0,                     // Масштабируем без изменений
+// handleWatermarkUpload обрабатывает POST /watermark: принимает
+// multipart-форму с полями "image" (обязательное) и "watermark"
+// (опциональное графическое изображение), а также параметры запроса,
+// описанные в baseWatermarkFromQuery, и возвращает готовые байты
+// изображения с корректным Content-Type
+func handleWatermarkUpload(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, fmt.Sprintf("invalid multipart form: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	// Загружаем первое изображение
-	srcImgFile1, err := os.Open("image.jpg") // Открываем файл изображения
+	imageFile, _, err := r.FormFile("image")
 	if err != nil {
-		panic(err) // Обрабатываем ошибку
+		http.Error(w, "missing required \"image\" form file", http.StatusBadRequest)
+		return
 	}
-	defer srcImgFile1.Close()                       // Закрываем файл после работы
-	baseImage1, _, err := image.Decode(srcImgFile1) // Декодируем изображение
+	defer imageFile.Close()
+
+	data, err := io.ReadAll(imageFile)
 	if err != nil {
-		panic(err) // Обрабатываем ошибку
+		http.Error(w, fmt.Sprintf("cannot read image: %v", err), http.StatusBadRequest)
+		return
 	}
-	watermarkedImage1 := imageWatermark.ApplyToImage(baseImage1) // Применяем водяной знак
 
-	// Создаем текстовый водяной знак
-	textWatermark := &TextWatermark{
-		BaseWatermark: BaseWatermark{
-			Opacity: 0.6,                           // Прозрачность текста
-			Color:   color.RGBA{239, 250, 23, 255}, // Цвет текста
-			Font:    "Nunito-Medium.ttf",           // Путь к используемому шрифту
-			Size:    35,                            // Размер текста
-			Rotate:  -29.5,                         // Угол поворота текста
-		},
-		Text: "пятаяпередача.рф", // Текст водяного знака
+	var watermarkImage image.Image
+	if watermarkFile, _, err := r.FormFile("watermark"); err == nil {
+		defer watermarkFile.Close()
+		if watermarkImage, _, err = image.Decode(watermarkFile); err != nil {
+			http.Error(w, fmt.Sprintf("cannot decode watermark: %v", err), http.StatusBadRequest)
+			return
+		}
 	}
 
-	// Загружаем второе изображение
-	srcImgFile2, err := os.Open("zerkalo-ozera.jpg") // Открываем файл второго изображения
+	respondWatermarked(w, r, data, watermarkImage)
+}
+
+// validateFetchURL проверяет src перед выполнением HTTP-запроса к нему, не
+// допуская SSRF: схема ограничена http/https, а хост не должен резолвиться в
+// loopback/частный/link-local адрес, иначе обработчик превращается в прокси
+// к внутренней сети сервера
+func validateFetchURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		panic(err) // Обрабатываем ошибку
+		return nil, fmt.Errorf("invalid src URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported src scheme %q", u.Scheme)
 	}
-	defer srcImgFile2.Close()                       // Закрываем файл после работы
-	baseImage2, _, err := image.Decode(srcImgFile2) // Декодируем второе изображение
+	host := u.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("src URL has no host")
+	}
+
+	ips, err := net.LookupIP(host)
 	if err != nil {
-		panic(err) // Обрабатываем ошибку
+		return nil, fmt.Errorf("cannot resolve src host %q: %w", host, err)
+	}
+	for _, ip := range ips {
+		if isDisallowedFetchIP(ip) {
+			return nil, fmt.Errorf("src host %q resolves to a disallowed address", host)
+		}
 	}
-	watermarkedImage2 := textWatermark.CreateWatermarkedImage(baseImage2) // Применяем текстовый водяной знак
 
-	// Кодируем итоговые изображения в формат Base64
-	imageWithWatermarkBase64_1 := encodeImageToBase64(watermarkedImage1, "jpeg")
-	imageWithWatermarkBase64_2 := encodeImageToBase64(watermarkedImage2, "jpeg")
+	return u, nil
+}
 
-	// Используем шаблонизатор для отображения изображений
-	tmpl, err := template.ParseFiles("templates/images.html") // Загружаем шаблон HTML
+// isDisallowedFetchIP сообщает, указывает ли ip на сам сервер или на
+// внутреннюю сеть, недоступную для обычных внешних URL
+func isDisallowedFetchIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// handleWatermarkFromURL обрабатывает GET /watermark?src=<url>&text=...:
+// загружает исходное изображение по URL, указанному в параметре src,
+// накладывает на него водяной знак по остальным параметрам запроса и
+// возвращает готовые байты изображения
+func handleWatermarkFromURL(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	if src == "" {
+		http.Error(w, "missing required \"src\" query parameter", http.StatusBadRequest)
+		return
+	}
+
+	u, err := validateFetchURL(src)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := fetchHTTPClient.Get(u.String())
 	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError) // Обрабатываем ошибку загрузки шаблона
+		http.Error(w, fmt.Sprintf("cannot fetch src: %v", err), http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	// Подготовка данных для передачи в шаблон
-	data := struct {
-		Image1 string // Кодированное изображение с водяным знаком 1
-		Image2 string // Кодированное изображение с водяным знаком 2
-	}{
-		Image1: imageWithWatermarkBase64_1, // Передаем первое изображение
-		Image2: imageWithWatermarkBase64_2, // Передаем второе изображение
+	if resp.StatusCode != http.StatusOK {
+		http.Error(w, fmt.Sprintf("src returned status %s", resp.Status), http.StatusBadGateway)
+		return
 	}
 
-	// Устанавливаем заголовок и выполняем шаблон
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError) // Обрабатываем ошибку при выполнении шаблона
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBodyBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot read src body: %v", err), http.StatusBadGateway)
+		return
 	}
+
+	respondWatermarked(w, r, data, nil)
 }
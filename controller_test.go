@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	cases := []struct {
+		name    string
+		hex     string
+		want    color.Color
+		wantErr bool
+	}{
+		{"with hash", "#ff0080", color.RGBA{R: 0xff, G: 0x00, B: 0x80, A: 255}, false},
+		{"without hash", "00ff00", color.RGBA{R: 0x00, G: 0xff, B: 0x00, A: 255}, false},
+		{"too short", "fff", nil, true},
+		{"not hex", "#gggggg", nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseHexColor(c.hex)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseHexColor(%q) expected error, got none", c.hex)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHexColor(%q) unexpected error: %v", c.hex, err)
+			}
+			if got != c.want {
+				t.Errorf("parseHexColor(%q) = %#v, want %#v", c.hex, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	cases := []struct {
+		name    string
+		s       string
+		want    Position
+		wantErr bool
+	}{
+		{"empty defaults to center", "", Center, false},
+		{"center", "center", Center, false},
+		{"top-left", "top-left", TopLeft, false},
+		{"top-right", "top-right", TopRight, false},
+		{"bottom-left", "bottom-left", BottomLeft, false},
+		{"bottom-right", "bottom-right", BottomRight, false},
+		{"tile", "tile", Tile, false},
+		{"case insensitive", "TOP-LEFT", TopLeft, false},
+		{"unknown", "diagonal", Center, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parsePosition(c.s)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parsePosition(%q) expected error, got none", c.s)
+				}
+			} else if err != nil {
+				t.Fatalf("parsePosition(%q) unexpected error: %v", c.s, err)
+			}
+			if got != c.want {
+				t.Errorf("parsePosition(%q) = %v, want %v", c.s, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+)
+
+// ApplyToGIF накладывает водяной знак wm на анимацию, сохраняя задержки
+// кадров и количество повторов. В отличие от покадрового наложения на
+// изолированный холст, кадры последовательно сводятся на общий холст с
+// учетом их собственного метода очистки (Disposal), как это делает любой
+// GIF-декодер перед отрисовкой следующего кадра — иначе дельта-кадры
+// (рисующие лишь часть канвы) и DisposalPrevious/DisposalBackground дают
+// испорченную картинку. Каждый выходной кадр уже содержит полностью
+// сведенное и обработанное изображение, поэтому для результата
+// используется DisposalNone, а квантование в индексированный кадр
+// выполняется по палитре исходного кадра, а не по фиксированной palette.Plan9
+func ApplyToGIF(src *gif.GIF, wm Watermarker) (*gif.GIF, error) {
+	canvas := image.Rect(0, 0, src.Config.Width, src.Config.Height)
+	accumulated := image.NewRGBA(canvas)
+
+	out := &gif.GIF{
+		Image:           make([]*image.Paletted, len(src.Image)),
+		Delay:           src.Delay,
+		LoopCount:       src.LoopCount,
+		Disposal:        make([]byte, len(src.Image)),
+		Config:          src.Config,
+		BackgroundIndex: src.BackgroundIndex,
+	}
+
+	var snapshot *image.RGBA
+	for i, frame := range src.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(src.Disposal) {
+			disposal = src.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			snapshot = image.NewRGBA(canvas)
+			draw.Draw(snapshot, canvas, accumulated, canvas.Min, draw.Src)
+		}
+
+		draw.Draw(accumulated, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		watermarked, err := wm.Apply(accumulated)
+		if err != nil {
+			return nil, fmt.Errorf("watermark gif frame %d: %w", i, err)
+		}
+
+		paletted := image.NewPaletted(canvas, frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, canvas, watermarked, canvas.Min)
+		out.Image[i] = paletted
+		out.Disposal[i] = gif.DisposalNone
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(accumulated, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(accumulated, canvas, snapshot, canvas.Min, draw.Src)
+		}
+	}
+
+	return out, nil
+}
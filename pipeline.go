@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// Pipeline последовательно применяет несколько водяных знаков к одному
+// изображению, например логотип в правом нижнем углу, поверх которого
+// идет повторяющийся диагональный текст и отметка времени в левом верхнем
+// углу. Каждый этап хранит собственные настройки позиции, прозрачности и
+// поворота. Pipeline сам реализует интерфейс Watermarker, поэтому конвейеры
+// можно вкладывать друг в друга
+type Pipeline struct {
+	Stages []Watermarker // Этапы конвейера, применяются по порядку
+}
+
+// Apply применяет этапы конвейера по очереди, передавая результат одного
+// этапа на вход следующему
+func (p *Pipeline) Apply(baseImage image.Image) (image.Image, error) {
+	result := baseImage
+	for i, stage := range p.Stages {
+		var err error
+		result, err = stage.Apply(result)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "image"
+
+// Position задает точку привязки водяного знака на изображении
+type Position int
+
+const (
+	TopLeft     Position = iota // Верхний левый угол
+	TopRight                    // Верхний правый угол
+	BottomLeft                  // Нижний левый угол
+	BottomRight                 // Нижний правый угол
+	Center                      // Центр изображения
+	Tile                        // Повтор водяного знака по всему изображению
+)
+
+// anchorPoint вычисляет координаты левого верхнего угла накладываемого
+// контента (contentW x contentH) внутри контейнера (containerW x containerH)
+// для заданной позиции, со сдвигом на dx, dy
+func anchorPoint(position Position, containerW, containerH, contentW, contentH, dx, dy float64) image.Point {
+	var x, y float64
+	switch position {
+	case TopLeft:
+		x, y = 0, 0
+	case TopRight:
+		x, y = containerW-contentW, 0
+	case BottomLeft:
+		x, y = 0, containerH-contentH
+	case BottomRight:
+		x, y = containerW-contentW, containerH-contentH
+	default: // Center и любое неизвестное значение
+		x, y = (containerW-contentW)/2, (containerH-contentH)/2
+	}
+	return image.Point{X: int(x + dx), Y: int(y + dy)}
+}
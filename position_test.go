@@ -0,0 +1,36 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestAnchorPoint(t *testing.T) {
+	const containerW, containerH = 200.0, 100.0
+	const contentW, contentH = 50.0, 20.0
+
+	cases := []struct {
+		name     string
+		position Position
+		dx, dy   float64
+		want     image.Point
+	}{
+		{"top-left", TopLeft, 0, 0, image.Point{X: 0, Y: 0}},
+		{"top-right", TopRight, 0, 0, image.Point{X: 150, Y: 0}},
+		{"bottom-left", BottomLeft, 0, 0, image.Point{X: 0, Y: 80}},
+		{"bottom-right", BottomRight, 0, 0, image.Point{X: 150, Y: 80}},
+		{"center", Center, 0, 0, image.Point{X: 75, Y: 40}},
+		{"unknown falls back to center", Position(99), 0, 0, image.Point{X: 75, Y: 40}},
+		{"top-left with offset", TopLeft, 5, 10, image.Point{X: 5, Y: 10}},
+		{"bottom-right with negative offset", BottomRight, -5, -10, image.Point{X: 145, Y: 70}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := anchorPoint(c.position, containerW, containerH, contentW, contentH, c.dx, c.dy)
+			if got != c.want {
+				t.Errorf("anchorPoint(%v, dx=%v, dy=%v) = %v, want %v", c.position, c.dx, c.dy, got, c.want)
+			}
+		})
+	}
+}
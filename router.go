@@ -1,18 +1,12 @@
 package main
 
 import (
-	"net/http"
-
 	"github.com/gorilla/mux"
 )
 
 func setupRouter() *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/", serveImages).Methods("GET")
+	r.HandleFunc("/watermark", handleWatermarkUpload).Methods("POST")
+	r.HandleFunc("/watermark", handleWatermarkFromURL).Methods("GET")
 	return r
 }
-
-func serveImages(w http.ResponseWriter, r *http.Request) {
-	// Вызов функции контроллера для обработки запроса
-	handleWatermarkedImages(w, r)
-}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+
+	"github.com/fogleman/gg"
+	"github.com/nfnt/resize"
+)
+
+// BaseWatermark содержит основные параметры для водяного знака
+type BaseWatermark struct {
+	Opacity  float64     // Прозрачность водяного знака
+	Color    color.Color // Цвет водяного знака
+	Font     string      // Шрифт для текстового водяного знака
+	Size     float64     // Размер текста водяного знака
+	Rotate   float64     // Угол вращения текста водяного знака
+	Position Position    // Точка привязки водяного знака (по умолчанию TopLeft, используйте Center для прежнего поведения)
+	Dx       float64     // Смещение по горизонтали относительно выбранной позиции (шаг тайла в режиме Tile)
+	Dy       float64     // Смещение по вертикали относительно выбранной позиции (шаг тайла в режиме Tile)
+}
+
+// Watermark представляет собой графический водяной знак
+type Watermark struct {
+	BaseWatermark         // Встраивание базовых параметров
+	Path          string  // Путь к файлу изображения водяного знака
+	Scale         float64 // Масштабирование водяного знака
+}
+
+// TextWatermark представляет собой текстовый водяной знак
+type TextWatermark struct {
+	BaseWatermark        // Встраивание базовых параметров
+	Text          string // Текст для водяного знака
+}
+
+// Watermarker накладывает водяной знак на изображение. Watermark,
+// TextWatermark и Pipeline реализуют этот интерфейс, что позволяет
+// составлять их в единый конвейер обработки
+type Watermarker interface {
+	Apply(baseImage image.Image) (image.Image, error)
+}
+
+// CreateImage создает изображение с текстовым водяным знаком. Для угловых
+// позиций текст поворачивается вокруг собственного центра, а для Center и
+// Tile используется техника поворота целого холста по диагонали, чтобы
+// повернутый текст не обрезался по краям (см. createDiagonalImage)
+func (t *TextWatermark) CreateImage(width, height float64) (image.Image, error) {
+	angle := -t.Rotate * (math.Pi / 180) // Преобразуем угол в радианы
+
+	switch t.Position {
+	case TopLeft, TopRight, BottomLeft, BottomRight:
+		return t.createCornerImage(width, height, angle)
+	default:
+		return t.createDiagonalImage(width, height, angle)
+	}
+}
+
+// createCornerImage рисует текст у одного из углов изображения, поворачивая
+// его вокруг собственного центра
+func (t *TextWatermark) createCornerImage(width, height, angle float64) (image.Image, error) {
+	dc := gg.NewContext(int(width), int(height)) // Создаем новый контекст рисования
+	dc.SetRGBA(1, 1, 1, 0)                       // Устанавливаем прозрачный фон
+	dc.Clear()                                   // Очищаем контекст
+	dc.SetColor(t.Color)                         // Устанавливаем цвет текста
+	if err := dc.LoadFontFace(t.Font, t.Size); err != nil {
+		return nil, fmt.Errorf("load font %q: %w", t.Font, err)
+	}
+
+	tw, th := dc.MeasureString(t.Text)
+	anchor := anchorPoint(t.Position, width, height, tw, th, t.Dx, t.Dy)
+	cx, cy := float64(anchor.X)+tw/2, float64(anchor.Y)+th/2
+	dc.Push()
+	dc.RotateAbout(angle, cx, cy)
+	dc.DrawStringAnchored(t.Text, cx, cy, 0.5, 0.5)
+	dc.Pop()
+	return dc.Image(), nil
+}
+
+// tileStep вычисляет шаг повторения элемента в режиме Tile: размер
+// элемента плюс зазор gap. Если итоговый шаг не положителен (например,
+// из-за чрезмерно отрицательного gap), элементы накладывались бы друг на
+// друга без продвижения, поэтому шаг схлопывается до самого размера
+func tileStep(size, gap float64) float64 {
+	step := size + gap
+	if step <= 0 {
+		return size
+	}
+	return step
+}
+
+// diagonalCanvasSize возвращает сторону квадратного холста, достаточную,
+// чтобы изображение width x height, повернутое на любой угол, не
+// обрезалось по краям — это диагональ самого изображения
+func diagonalCanvasSize(width, height float64) float64 {
+	return math.Ceil(math.Hypot(width, height))
+}
+
+// diagonalCropRect возвращает прямоугольник width x height, вырезаемый из
+// центра квадратного холста стороной diagonal
+func diagonalCropRect(diagonal, width, height float64) image.Rectangle {
+	offsetX, offsetY := (diagonal-width)/2, (diagonal-height)/2
+	return image.Rect(int(offsetX), int(offsetY), int(offsetX+width), int(offsetY+height))
+}
+
+// createDiagonalImage рисует (при необходимости повторяющийся) текст на
+// промежуточном квадратном холсте со стороной, равной диагонали
+// результирующего изображения, поворачивает холст целиком вокруг его
+// центра, а затем обрезает центральную область width x height. Так
+// повернутый текст заполняет изображение от края до края при любом угле,
+// не обрезаясь по границам, и позволяет строить настоящий повторяющийся
+// диагональный узор в режиме Tile
+func (t *TextWatermark) createDiagonalImage(width, height, angle float64) (image.Image, error) {
+	diagonal := diagonalCanvasSize(width, height)
+
+	square := gg.NewContext(int(diagonal), int(diagonal))
+	square.SetRGBA(1, 1, 1, 0)
+	square.Clear()
+	square.SetColor(t.Color)
+	if err := square.LoadFontFace(t.Font, t.Size); err != nil {
+		return nil, fmt.Errorf("load font %q: %w", t.Font, err)
+	}
+
+	tw, th := square.MeasureString(t.Text)
+
+	if t.Position == Tile {
+		// Рисуем текст по всему холсту строками и столбцами с шагом Dx/Dy,
+		// чтобы после поворота получить повторяющийся диагональный узор,
+		// заполняющий изображение от края до края (аналогично 2D-тайлингу
+		// графического водяного знака в applyWatermarkImage)
+		stepX := tileStep(tw, t.Dx)
+		stepY := tileStep(th, t.Dy)
+		for y := -stepY; y < diagonal+stepY; y += stepY {
+			for x := -stepX; x < diagonal+stepX; x += stepX {
+				square.DrawStringAnchored(t.Text, x, y, 0, 0.5)
+			}
+		}
+	} else {
+		square.DrawStringAnchored(t.Text, diagonal/2, diagonal/2, 0.5, 0.5)
+	}
+
+	rotated := gg.NewContext(int(diagonal), int(diagonal))
+	rotated.SetRGBA(1, 1, 1, 0)
+	rotated.Clear()
+	rotated.RotateAbout(angle, diagonal/2, diagonal/2)
+	rotated.DrawImage(square.Image(), 0, 0)
+
+	// Обрезаем центральную область исходного размера из повернутого холста
+	crop := diagonalCropRect(diagonal, width, height)
+
+	result := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	draw.Draw(result, result.Bounds(), rotated.Image(), crop.Min, draw.Src)
+	return result, nil
+}
+
+// Apply загружает графический водяной знак из w.Path и накладывает его на
+// baseImage, реализуя интерфейс Watermarker
+func (w *Watermark) Apply(baseImage image.Image) (image.Image, error) {
+	if w.Path == "" { // Проверяем, задан ли путь к изображению водяного знака
+		result := image.NewRGBA(baseImage.Bounds())
+		draw.Draw(result, result.Bounds(), baseImage, baseImage.Bounds().Min, draw.Over)
+		return result, nil
+	}
+
+	watermarkFile, err := os.Open(w.Path) // Открываем файл водяного знака
+	if err != nil {
+		return nil, fmt.Errorf("open watermark %q: %w", w.Path, err)
+	}
+	defer watermarkFile.Close()                           // Закрываем файл после завершения работы
+	watermarkImage, _, err := image.Decode(watermarkFile) // Декодируем изображение водяного знака (PNG, JPEG или GIF)
+	if err != nil {
+		return nil, fmt.Errorf("decode watermark %q: %w", w.Path, err)
+	}
+
+	return w.applyWatermarkImage(baseImage, watermarkImage), nil
+}
+
+// applyWatermarkImage накладывает уже декодированное изображение водяного
+// знака на базовое изображение; вынесено отдельно от Apply, чтобы
+// вызывающий код (например, HTTP-обработчик) мог передать изображение,
+// полученное не из файла на диске, а напрямую из запроса
+func (w *Watermark) applyWatermarkImage(baseImage, watermarkImage image.Image) image.Image {
+	bounds := baseImage.Bounds()                                // Получаем границы базового изображения
+	result := image.NewRGBA(bounds)                             // Создаем новое изображение для результата
+	draw.Draw(result, bounds, baseImage, bounds.Min, draw.Over) // Рисуем базовое изображение на результирующем
+
+	if w.Scale != 1 { // Проверяем, требуется ли изменение масштаба
+		wmBounds := watermarkImage.Bounds()                                                  // Получаем границы изображения водяного знака
+		newWidth := uint(float64(wmBounds.Dx()) * w.Scale)                                   // Рассчитываем новый размер по ширине
+		newHeight := uint(float64(wmBounds.Dy()) * w.Scale)                                  // Рассчитываем новый размер по высоте
+		watermarkImage = resize.Resize(newWidth, newHeight, watermarkImage, resize.Bilinear) // Масштабируем изображение
+	}
+
+	wmBounds := watermarkImage.Bounds()
+
+	if w.Position == Tile {
+		// В режиме Tile повторяем водяной знак по всему изображению с шагом Dx/Dy
+		stepX := int(tileStep(float64(wmBounds.Dx()), w.Dx))
+		stepY := int(tileStep(float64(wmBounds.Dy()), w.Dy))
+		for y := 0; y < bounds.Dy(); y += stepY {
+			for x := 0; x < bounds.Dx(); x += stepX {
+				offset := image.Point{X: x, Y: y}
+				draw.Draw(result, wmBounds.Add(offset), watermarkImage, image.Point{}, draw.Over)
+			}
+		}
+	} else {
+		// Рассчитываем позицию накладываемого водяного знака по выбранному якорю
+		offset := anchorPoint(w.Position, float64(bounds.Dx()), float64(bounds.Dy()), float64(wmBounds.Dx()), float64(wmBounds.Dy()), w.Dx, w.Dy)
+		draw.Draw(result, wmBounds.Add(offset), watermarkImage, image.Point{}, draw.Over)
+	}
+
+	return result // Возвращаем изображение с наложенным водяным знаком
+}
+
+// Apply рендерит текстовый водяной знак и накладывает его на baseImage,
+// реализуя интерфейс Watermarker
+func (t *TextWatermark) Apply(baseImage image.Image) (image.Image, error) {
+	textImage, err := t.CreateImage(float64(baseImage.Bounds().Dx()), float64(baseImage.Bounds().Dy())) // Генерируем текстовый водяной знак
+	if err != nil {
+		return nil, err
+	}
+
+	result := image.NewRGBA(baseImage.Bounds())                                         // Создаем блок для результата
+	draw.Draw(result, baseImage.Bounds(), baseImage, baseImage.Bounds().Min, draw.Over) // Копируем базовое изображение в результат
+
+	// Накладываем текстовый водяной знак
+	draw.DrawMask(result, result.Bounds(), textImage, image.Point{}, &image.Uniform{color.Alpha{uint8(255 * t.Opacity)}}, image.Point{}, draw.Over)
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestTileStep(t *testing.T) {
+	cases := []struct {
+		name string
+		size float64
+		gap  float64
+		want float64
+	}{
+		{"positive gap adds to size", 20, 5, 25},
+		{"zero gap keeps size", 20, 0, 20},
+		{"small negative gap still positive", 20, -5, 15},
+		{"gap collapsing step falls back to size", 20, -20, 20},
+		{"gap overshooting step falls back to size", 20, -30, 20},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := tileStep(c.size, c.gap); got != c.want {
+				t.Errorf("tileStep(%v, %v) = %v, want %v", c.size, c.gap, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDiagonalCanvasSize(t *testing.T) {
+	got := diagonalCanvasSize(3, 4)
+	if got != 5 {
+		t.Errorf("diagonalCanvasSize(3, 4) = %v, want 5", got)
+	}
+
+	got = diagonalCanvasSize(100, 100)
+	want := math.Ceil(math.Hypot(100, 100))
+	if got != want {
+		t.Errorf("diagonalCanvasSize(100, 100) = %v, want %v", got, want)
+	}
+}
+
+func TestDiagonalCropRect(t *testing.T) {
+	diagonal := diagonalCanvasSize(100, 50)
+	crop := diagonalCropRect(diagonal, 100, 50)
+
+	if crop.Dx() != 100 || crop.Dy() != 50 {
+		t.Fatalf("diagonalCropRect size = %dx%d, want 100x50", crop.Dx(), crop.Dy())
+	}
+
+	// The crop rectangle must be centered on the square canvas
+	wantMin := image.Point{X: int((diagonal - 100) / 2), Y: int((diagonal - 50) / 2)}
+	if crop.Min != wantMin {
+		t.Errorf("diagonalCropRect min = %v, want %v", crop.Min, wantMin)
+	}
+}